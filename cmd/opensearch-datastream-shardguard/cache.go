@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// scrapeCache memoizes OpenSearch API responses for cacheTTL, coalescing
+// concurrent callers for the same key through singleflight so that multiple
+// Prometheus scrapers hitting /metrics (or /probe) at once don't each
+// trigger their own round trip to the cluster.
+type scrapeCache struct {
+	ttl time.Duration
+	sf  singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	expiresAt time.Time
+	value     interface{}
+}
+
+func newScrapeCache(ttl time.Duration) *scrapeCache {
+	return &scrapeCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// get returns the cached value for key if it hasn't expired.
+func (c *scrapeCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// fetch returns the cached value for key, or calls fn (coalescing concurrent
+// callers for the same key) and caches the result for ttl.
+func (c *scrapeCache) fetch(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if c.ttl <= 0 {
+		return fn()
+	}
+
+	if v, ok := c.get(key); ok {
+		return v, nil
+	}
+
+	v, err, _ := c.sf.Do(key, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{expiresAt: time.Now().Add(c.ttl), value: v}
+	c.mu.Unlock()
+
+	return v, nil
+}
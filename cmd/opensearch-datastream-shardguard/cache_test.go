@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeCacheFetchCachesWithinTTL(t *testing.T) {
+	c := newScrapeCache(time.Minute)
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := c.fetch("key", fn)
+		if err != nil {
+			t.Fatalf("fetch: unexpected error: %v", err)
+		}
+		if v != "value" {
+			t.Fatalf("fetch: got %v, want %q", v, "value")
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times, want 1 (result should be cached)", got)
+	}
+}
+
+func TestScrapeCacheFetchRefetchesAfterExpiry(t *testing.T) {
+	c := newScrapeCache(time.Millisecond)
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+
+	if _, err := c.fetch("key", fn); err != nil {
+		t.Fatalf("fetch: unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := c.fetch("key", fn); err != nil {
+		t.Fatalf("fetch: unexpected error: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn called %d times, want 2 (expired entry should refetch)", got)
+	}
+}
+
+func TestScrapeCacheFetchBypassedWhenTTLZero(t *testing.T) {
+	c := newScrapeCache(0)
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.fetch("key", fn); err != nil {
+			t.Fatalf("fetch: unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("fn called %d times, want 3 (ttl<=0 must bypass the cache)", got)
+	}
+}
+
+func TestScrapeCacheFetchDoesNotCacheErrors(t *testing.T) {
+	c := newScrapeCache(time.Minute)
+
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errBoom
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.fetch("key", fn); err != errBoom {
+			t.Fatalf("fetch: got err %v, want %v", err, errBoom)
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn called %d times, want 2 (errors must not be cached)", got)
+	}
+}
+
+var errBoom = &cacheTestError{"boom"}
+
+type cacheTestError struct{ msg string }
+
+func (e *cacheTestError) Error() string { return e.msg }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TargetConfig describes a single named OpenSearch cluster that the exporter
+// can be asked to probe via /probe?target=<url>.
+type TargetConfig struct {
+	Name               string  `yaml:"name"`
+	URL                string  `yaml:"url"`
+	Auth               string  `yaml:"auth"` // "basic", "iam", or "none"
+	Username           string  `yaml:"username"`
+	Password           string  `yaml:"password"`
+	AWSRegion          string  `yaml:"aws_region"`
+	AWSService         string  `yaml:"aws_service"`
+	AWSRoleARN         string  `yaml:"aws_role_arn"`
+	AWSRoleSessionName string  `yaml:"aws_role_session_name"`
+	AWSExternalID      string  `yaml:"aws_external_id"`
+	TimeoutSeconds     int     `yaml:"timeout_seconds"`
+	TargetShardSizeGB  float64 `yaml:"target_shard_size_gb"`
+}
+
+// Config is the top-level shape of the YAML file referenced by
+// OPENSEARCH_PROBE_CONFIG. It lists the fleet of OpenSearch domains a single
+// exporter instance is responsible for.
+type Config struct {
+	Targets []TargetConfig `yaml:"targets"`
+}
+
+// loadConfig reads and parses the probe targets file at path.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse probe config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// findTarget looks up a configured target by its URL, as supplied via the
+// /probe?target= query parameter.
+func (c *Config) findTarget(url string) (*TargetConfig, bool) {
+	if c == nil {
+		return nil, false
+	}
+	for i := range c.Targets {
+		if c.Targets[i].URL == url {
+			return &c.Targets[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// parseGlobList splits a comma-separated list of glob patterns (as used by
+// DATASTREAM_INCLUDE / DATASTREAM_EXCLUDE) into a slice, trimming whitespace
+// and dropping empty entries.
+func parseGlobList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	patterns := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matchesAnyGlob reports whether name matches at least one of the given glob
+// patterns. Patterns use path.Match syntax (e.g. "logs-*", ".internal-*").
+func matchesAnyGlob(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dataStreamAllowed reports whether a data stream name passes the exporter's
+// include/exclude filters. An empty include list allows everything except
+// what exclude matches; exclude always takes precedence over include.
+func (e *Exporter) dataStreamAllowed(name string) bool {
+	if len(e.dataStreamExclude) > 0 && matchesAnyGlob(name, e.dataStreamExclude) {
+		return false
+	}
+	if len(e.dataStreamInclude) > 0 {
+		return matchesAnyGlob(name, e.dataStreamInclude)
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestParseGlobList(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"logs-*", []string{"logs-*"}},
+		{"logs-*, metrics-*", []string{"logs-*", "metrics-*"}},
+		{" logs-*,, metrics-* ", []string{"logs-*", "metrics-*"}},
+	}
+	for _, tc := range cases {
+		got := parseGlobList(tc.in)
+		if len(got) != len(tc.want) {
+			t.Errorf("parseGlobList(%q) = %v, want %v", tc.in, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("parseGlobList(%q) = %v, want %v", tc.in, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchesAnyGlob(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		want     bool
+	}{
+		{"logs-2024", []string{"logs-*"}, true},
+		{"metrics-2024", []string{"logs-*"}, false},
+		{".internal-audit", []string{"logs-*", ".internal-*"}, true},
+		{"anything", nil, false},
+	}
+	for _, tc := range cases {
+		if got := matchesAnyGlob(tc.name, tc.patterns); got != tc.want {
+			t.Errorf("matchesAnyGlob(%q, %v) = %v, want %v", tc.name, tc.patterns, got, tc.want)
+		}
+	}
+}
+
+func TestDataStreamAllowed(t *testing.T) {
+	cases := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    bool
+	}{
+		{"logs-app", nil, nil, true},
+		{"logs-app", []string{"logs-*"}, nil, true},
+		{"metrics-app", []string{"logs-*"}, nil, false},
+		{"logs-app", nil, []string{"logs-*"}, false},
+		{"logs-app", []string{"logs-*"}, []string{"logs-*"}, false},
+	}
+	for _, tc := range cases {
+		e := &Exporter{dataStreamInclude: tc.include, dataStreamExclude: tc.exclude}
+		if got := e.dataStreamAllowed(tc.name); got != tc.want {
+			t.Errorf("dataStreamAllowed(%q) with include=%v exclude=%v = %v, want %v",
+				tc.name, tc.include, tc.exclude, got, tc.want)
+		}
+	}
+}
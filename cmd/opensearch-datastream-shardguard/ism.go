@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------ ISM API response structs ------------
+
+// /_plugins/_ism/policies
+type ismPoliciesResponse struct {
+	Policies []struct {
+		Policy ismPolicy `json:"policy"`
+	} `json:"policies"`
+}
+
+type ismPolicy struct {
+	PolicyID string `json:"policy_id"`
+	States   []struct {
+		Actions []struct {
+			Rollover *ismRolloverCondition `json:"rollover,omitempty"`
+		} `json:"actions"`
+	} `json:"states"`
+}
+
+type ismRolloverCondition struct {
+	MinSize             string `json:"min_size"`
+	MinPrimaryShardSize string `json:"min_primary_shard_size"`
+	MinDocCount         int64  `json:"min_doc_count"`
+}
+
+// rolloverCondition returns the first rollover action's conditions found in
+// any state of the policy, or nil if the policy never rolls over.
+func (p ismPolicy) rolloverCondition() *ismRolloverCondition {
+	for _, state := range p.States {
+		for _, action := range state.Actions {
+			if action.Rollover != nil {
+				return action.Rollover
+			}
+		}
+	}
+	return nil
+}
+
+// /_plugins/_ism/explain/<index>
+type ismExplainResponse struct {
+	// keyed by index name; ISM mixes metadata fields (e.g. "total_managed_indices")
+	// into the same object, so entries are decoded individually below.
+	Indices map[string]ismExplainIndex
+}
+
+type ismExplainIndex struct {
+	PolicyID string `json:"policy_id"`
+}
+
+func (r *ismExplainResponse) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Indices = make(map[string]ismExplainIndex, len(raw))
+	for name, msg := range raw {
+		var entry ismExplainIndex
+		if err := json.Unmarshal(msg, &entry); err != nil {
+			// skip non-index metadata fields such as "total_managed_indices"
+			continue
+		}
+		if entry.PolicyID != "" {
+			r.Indices[name] = entry
+		}
+	}
+	return nil
+}
+
+// ------------ byte size parsing ------------
+
+// parseISMByteSize parses OpenSearch ISM size strings such as "50gb" or
+// "100mb" into bytes. An empty string means the condition is unset.
+func parseISMByteSize(s string) (float64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"gb", 1024 * 1024 * 1024},
+		{"mb", 1024 * 1024},
+		{"kb", 1024},
+		{"b", 1},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			numPart := strings.TrimSuffix(s, u.suffix)
+			val, err := strconv.ParseFloat(numPart, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid ISM size value %q: %w", s, err)
+			}
+			return val * u.multiplier, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized ISM size unit in %q", s)
+}
+
+// ------------ growth-rate tracking for time-to-rollover ------------
+
+type indexSizeSample struct {
+	takenAt   time.Time
+	sizeBytes float64
+}
+
+// rolloverProjection holds everything ism-related we could work out about a
+// single backing index for one Collect pass.
+type rolloverProjection struct {
+	policyID                 string
+	minPrimaryShardSizeBytes float64
+	recommendedPrimaryShards float64 // 0 means "no override, use the targetShardSizeBytes heuristic"
+	timeToRolloverSeconds    float64
+	haveTimeToRollover       bool
+}
+
+// fetchISMPolicies fetches all ISM policies and returns them keyed by policy_id.
+func (e *Exporter) fetchISMPolicies() (map[string]ismPolicy, error) {
+	resp, err := e.doRequest("GET", "/_plugins/_ism/policies")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed ismPoliciesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]ismPolicy, len(parsed.Policies))
+	for _, entry := range parsed.Policies {
+		policies[entry.Policy.PolicyID] = entry.Policy
+	}
+	return policies, nil
+}
+
+// fetchISMExplain fetches the ISM-managed state of the given indices.
+func (e *Exporter) fetchISMExplain(indices []string) (*ismExplainResponse, error) {
+	if len(indices) == 0 {
+		return &ismExplainResponse{Indices: map[string]ismExplainIndex{}}, nil
+	}
+
+	path := "/_plugins/_ism/explain/" + strings.Join(indices, ",")
+	resp, err := e.doRequest("GET", path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var explain ismExplainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&explain); err != nil {
+		return nil, err
+	}
+	return &explain, nil
+}
+
+// rolloverProjectionFor works out the rollover-aware projection for indexName
+// given its current primary store size and primary shard count, using cached
+// ISM policies/explain data and the exporter's own growth-rate history.
+//
+// min_size and min_primary_shard_size are different units: min_size is a
+// whole-index (sum of all primary shards) threshold, while
+// min_primary_shard_size is a per-shard threshold. They must not be merged
+// via max() before dividing by min_primary_shard_size, or a policy that only
+// sets min_primary_shard_size (the common case for data streams) always
+// collapses the recommendation to ceil(x/x) == 1 shard.
+func (e *Exporter) rolloverProjectionFor(indexName string, policies map[string]ismPolicy, explain *ismExplainResponse, primaryStoreBytes float64, primaryCount int) *rolloverProjection {
+	explainEntry, ok := explain.Indices[indexName]
+	if !ok || explainEntry.PolicyID == "" {
+		return nil
+	}
+
+	policy, ok := policies[explainEntry.PolicyID]
+	if !ok {
+		return nil
+	}
+
+	rollover := policy.rolloverCondition()
+	if rollover == nil {
+		return &rolloverProjection{policyID: explainEntry.PolicyID}
+	}
+
+	minPrimaryShardSizeBytes, err := parseISMByteSize(rollover.MinPrimaryShardSize)
+	if err != nil {
+		minPrimaryShardSizeBytes = 0
+	}
+	minSizeBytes, err := parseISMByteSize(rollover.MinSize)
+	if err != nil {
+		minSizeBytes = 0
+	}
+
+	proj := &rolloverProjection{
+		policyID:                 explainEntry.PolicyID,
+		minPrimaryShardSizeBytes: minPrimaryShardSizeBytes,
+	}
+
+	if minPrimaryShardSizeBytes > 0 {
+		switch {
+		case minSizeBytes > 0:
+			// min_size is the policy's own whole-index rollover threshold:
+			// recommend however many min_primary_shard_size-sized shards it
+			// takes to hold that much data.
+			proj.recommendedPrimaryShards = math.Ceil(minSizeBytes / minPrimaryShardSizeBytes)
+		case primaryStoreBytes > 0:
+			// No whole-index threshold is configured, so fall back to the
+			// index's actual current size: recommend however many shards it
+			// takes to keep each one at or under min_primary_shard_size right
+			// now. (Deriving this from primaryCount instead would just give
+			// back primaryCount itself, regardless of how over- or
+			// under-sized the shards actually are.)
+			proj.recommendedPrimaryShards = math.Ceil(primaryStoreBytes / minPrimaryShardSizeBytes)
+		}
+	}
+
+	switch {
+	case minSizeBytes > 0:
+		// min_size and primaryStoreBytes are both whole-index totals.
+		if ttr, ok := e.estimateTimeToRollover(indexName+":total", primaryStoreBytes, minSizeBytes); ok {
+			proj.timeToRolloverSeconds = ttr
+			proj.haveTimeToRollover = true
+		}
+	case minPrimaryShardSizeBytes > 0 && primaryCount > 0:
+		// Compare like with like: average primary shard size against the
+		// per-shard threshold, not the whole-index total.
+		avgShardSizeBytes := primaryStoreBytes / float64(primaryCount)
+		if ttr, ok := e.estimateTimeToRollover(indexName+":avg_shard", avgShardSizeBytes, minPrimaryShardSizeBytes); ok {
+			proj.timeToRolloverSeconds = ttr
+			proj.haveTimeToRollover = true
+		}
+	}
+
+	return proj
+}
+
+// estimateTimeToRollover compares the current sample against the last one
+// seen for this index to derive a growth rate, then projects forward to the
+// rollover threshold. It returns ok=false until a second sample is available.
+func (e *Exporter) estimateTimeToRollover(indexName string, currentSizeBytes, thresholdBytes float64) (float64, bool) {
+	e.sizeSamplesMu.Lock()
+	defer e.sizeSamplesMu.Unlock()
+
+	if e.sizeSamples == nil {
+		e.sizeSamples = make(map[string]indexSizeSample)
+	}
+
+	now := time.Now()
+	prev, hadPrev := e.sizeSamples[indexName]
+	e.sizeSamples[indexName] = indexSizeSample{takenAt: now, sizeBytes: currentSizeBytes}
+
+	if !hadPrev {
+		return 0, false
+	}
+
+	elapsed := now.Sub(prev.takenAt).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	growthPerSecond := (currentSizeBytes - prev.sizeBytes) / elapsed
+	if growthPerSecond <= 0 {
+		return 0, false
+	}
+
+	remaining := thresholdBytes - currentSizeBytes
+	if remaining <= 0 {
+		return 0, true
+	}
+
+	return remaining / growthPerSecond, true
+}
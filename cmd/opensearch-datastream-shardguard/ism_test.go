@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseISMByteSize(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"50gb", 50 * 1024 * 1024 * 1024, false},
+		{"100mb", 100 * 1024 * 1024, false},
+		{"10kb", 10 * 1024, false},
+		{"512b", 512, false},
+		{"1.5gb", 1.5 * 1024 * 1024 * 1024, false},
+		{"50GB", 50 * 1024 * 1024 * 1024, false},
+		{"notasize", 0, true},
+		{"gb", 0, true},
+	}
+	for _, tc := range cases {
+		got, err := parseISMByteSize(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseISMByteSize(%q): expected error, got %v", tc.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseISMByteSize(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseISMByteSize(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRolloverProjectionFor(t *testing.T) {
+	e := &Exporter{}
+
+	policies := map[string]ismPolicy{
+		"only-shard-size": {
+			PolicyID: "only-shard-size",
+			States: []struct {
+				Actions []struct {
+					Rollover *ismRolloverCondition `json:"rollover,omitempty"`
+				} `json:"actions"`
+			}{
+				{Actions: []struct {
+					Rollover *ismRolloverCondition `json:"rollover,omitempty"`
+				}{{Rollover: &ismRolloverCondition{MinPrimaryShardSize: "50gb"}}}},
+			},
+		},
+		"whole-index-size": {
+			PolicyID: "whole-index-size",
+			States: []struct {
+				Actions []struct {
+					Rollover *ismRolloverCondition `json:"rollover,omitempty"`
+				} `json:"actions"`
+			}{
+				{Actions: []struct {
+					Rollover *ismRolloverCondition `json:"rollover,omitempty"`
+				}{{Rollover: &ismRolloverCondition{MinSize: "200gb", MinPrimaryShardSize: "50gb"}}}},
+			},
+		},
+	}
+
+	gb := float64(1024 * 1024 * 1024)
+
+	t.Run("min_primary_shard_size only tracks actual index size, not primaryCount", func(t *testing.T) {
+		explain := &ismExplainResponse{Indices: map[string]ismExplainIndex{
+			"idx-1": {PolicyID: "only-shard-size"},
+		}}
+
+		// 2 primary shards, 400GB actual store size (200GB/shard - well over
+		// the 50GB threshold). The recommendation must reflect that, not
+		// collapse back to the current primaryCount of 2.
+		proj := e.rolloverProjectionFor("idx-1", policies, explain, 400*gb, 2)
+		if proj == nil {
+			t.Fatal("expected a non-nil projection")
+		}
+		if want := math.Ceil(400 * gb / (50 * gb)); proj.recommendedPrimaryShards != want {
+			t.Errorf("recommendedPrimaryShards = %v, want %v", proj.recommendedPrimaryShards, want)
+		}
+		if proj.recommendedPrimaryShards == 2 {
+			t.Errorf("recommendedPrimaryShards must not just echo primaryCount back")
+		}
+	})
+
+	t.Run("min_size present uses the whole-index threshold", func(t *testing.T) {
+		explain := &ismExplainResponse{Indices: map[string]ismExplainIndex{
+			"idx-2": {PolicyID: "whole-index-size"},
+		}}
+
+		proj := e.rolloverProjectionFor("idx-2", policies, explain, 10*gb, 2)
+		if proj == nil {
+			t.Fatal("expected a non-nil projection")
+		}
+		if want := math.Ceil(200 * gb / (50 * gb)); proj.recommendedPrimaryShards != want {
+			t.Errorf("recommendedPrimaryShards = %v, want %v", proj.recommendedPrimaryShards, want)
+		}
+	})
+
+	t.Run("unmanaged index yields no projection", func(t *testing.T) {
+		explain := &ismExplainResponse{Indices: map[string]ismExplainIndex{}}
+		if proj := e.rolloverProjectionFor("idx-3", policies, explain, 10*gb, 1); proj != nil {
+			t.Errorf("expected nil projection for unmanaged index, got %+v", proj)
+		}
+	})
+}
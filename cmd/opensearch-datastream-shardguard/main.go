@@ -8,16 +8,21 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
 
 	aws "github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 type Exporter struct {
@@ -34,19 +39,50 @@ type Exporter struct {
 	indexRecommendedDesc  *prometheus.Desc
 	indexShardOKDesc      *prometheus.Desc
 
-	useIAM           bool
-	awsSigner        *v4.Signer
-	awsRegion        string
-	awsService       string
-	awsCredsProvider aws.CredentialsProvider
+	useIAM             bool
+	awsSigner          *v4.Signer
+	awsRegion          string
+	awsService         string
+	awsCredsProvider   aws.CredentialsProvider
+	awsRoleARN         string
+	awsRoleSessionName string
+	awsExternalID      string
+
+	awsCredsMu     sync.Mutex
+	awsCachedCreds aws.Credentials
+	awsCredsCached bool
+
+	dataStreamInclude []string
+	dataStreamExclude []string
+
+	sizeSamplesMu sync.Mutex
+	sizeSamples   map[string]indexSizeSample
+
+	rolloverMinPrimaryShardSizeDesc *prometheus.Desc
+	ismPolicyInfoDesc               *prometheus.Desc
+	timeToRolloverDesc              *prometheus.Desc
+
+	nodeHeapMaxPercent   float64
+	nodeDiskMinFreeBytes float64
+
+	nodeHeapUsedPercentDesc *prometheus.Desc
+	nodeFSAvailableDesc     *prometheus.Desc
+	nodeShardCountDesc      *prometheus.Desc
+
+	cache *scrapeCache
 }
 
-func NewExporter(
+// NewExporterWithAssumeRole constructs an Exporter. When awsRoleARN is set,
+// the credentials loaded for IAM auth are wrapped in an
+// stscreds.AssumeRoleProvider so the exporter can scrape OpenSearch domains
+// in an account other than the one its own credentials belong to.
+func NewExporterWithAssumeRole(
 	baseURL, username, password string,
 	targetShardSizeBytes float64,
 	useIAM bool,
 	awsRegion, awsService string,
-) *Exporter {
+	awsRoleARN, awsRoleSessionName, awsExternalID string,
+) (*Exporter, error) {
 	labelNames := []string{"cluster", "data_stream", "index"}
 
 	exp := &Exporter{
@@ -60,6 +96,10 @@ func NewExporter(
 		useIAM:               useIAM,
 		awsRegion:            awsRegion,
 		awsService:           awsService,
+		awsRoleARN:           awsRoleARN,
+		awsRoleSessionName:   awsRoleSessionName,
+		awsExternalID:        awsExternalID,
+		cache:                newScrapeCache(30 * time.Second),
 		indexShardSizeDesc: prometheus.NewDesc(
 			"opensearch_datastream_primary_shard_size_bytes",
 			"Average size of primary shards for the latest backing index of a data stream, in bytes",
@@ -86,10 +126,46 @@ func NewExporter(
 		),
 		indexShardOKDesc: prometheus.NewDesc(
 			"opensearch_datastream_shard_size_ok",
-			"1 if avg primary shard size for latest backing index is less than or equal to target, 0 otherwise",
+			"1 if avg primary shard size is within target and no data node breaches NODE_HEAP_MAX_PERCENT/NODE_DISK_MIN_FREE_GB, 0 otherwise",
+			labelNames,
+			nil,
+		),
+		rolloverMinPrimaryShardSizeDesc: prometheus.NewDesc(
+			"opensearch_datastream_rollover_min_primary_shard_size_bytes",
+			"min_primary_shard_size rollover condition from the ISM policy governing this data stream, in bytes",
 			labelNames,
 			nil,
 		),
+		ismPolicyInfoDesc: prometheus.NewDesc(
+			"opensearch_datastream_ism_policy_info",
+			"Always 1; identifies the ISM policy governing the latest backing index of a data stream",
+			append(append([]string{}, labelNames...), "policy"),
+			nil,
+		),
+		timeToRolloverDesc: prometheus.NewDesc(
+			"opensearch_datastream_time_to_rollover_seconds",
+			"Estimated time until the ISM rollover size condition is met, based on current growth rate",
+			labelNames,
+			nil,
+		),
+		nodeHeapUsedPercentDesc: prometheus.NewDesc(
+			"opensearch_node_heap_used_percent",
+			"JVM heap used percent for a node",
+			[]string{"cluster", "node"},
+			nil,
+		),
+		nodeFSAvailableDesc: prometheus.NewDesc(
+			"opensearch_node_fs_available_bytes",
+			"Available filesystem bytes for a node",
+			[]string{"cluster", "node"},
+			nil,
+		),
+		nodeShardCountDesc: prometheus.NewDesc(
+			"opensearch_node_shard_count",
+			"Number of shards hosted on a node",
+			[]string{"cluster", "node"},
+			nil,
+		),
 	}
 
 	if useIAM {
@@ -107,14 +183,27 @@ func NewExporter(
 			awsconfig.WithRegion(awsRegion),
 		)
 		if err != nil {
-			log.Fatalf("failed to load AWS config for IAM auth: %v", err)
+			return nil, fmt.Errorf("failed to load AWS config for IAM auth: %w", err)
 		}
 
 		exp.awsSigner = v4.NewSigner()
 		exp.awsCredsProvider = cfg.Credentials
+
+		if awsRoleARN != "" {
+			if awsRoleSessionName == "" {
+				awsRoleSessionName = "opensearch-datastream-shardguard"
+			}
+			stsClient := sts.NewFromConfig(cfg)
+			exp.awsCredsProvider = stscreds.NewAssumeRoleProvider(stsClient, awsRoleARN, func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = awsRoleSessionName
+				if awsExternalID != "" {
+					o.ExternalID = aws.String(awsExternalID)
+				}
+			})
+		}
 	}
 
-	return exp
+	return exp, nil
 }
 
 // ------------ API response structs ------------
@@ -160,6 +249,12 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.indexPrimaryCountDesc
 	ch <- e.indexRecommendedDesc
 	ch <- e.indexShardOKDesc
+	ch <- e.rolloverMinPrimaryShardSizeDesc
+	ch <- e.ismPolicyInfoDesc
+	ch <- e.timeToRolloverDesc
+	ch <- e.nodeHeapUsedPercentDesc
+	ch <- e.nodeFSAvailableDesc
+	ch <- e.nodeShardCountDesc
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
@@ -172,7 +267,26 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	}
 	cluster := e.clusterName
 
-	// 2) Fetch data streams (to know which backing indices to care about)
+	// 2) Fetch per-node heap/disk/shard stats, and gate the composite
+	// shard_size_ok signal on cluster capacity as well as shard sizing.
+	clusterCapacityOK := true
+	if nodesStats, err := e.fetchNodesStats(); err != nil {
+		log.Printf("error fetching node stats: %v", err)
+	} else {
+		clusterCapacityOK = e.clusterCapacityOK(nodesStats)
+		for nodeID, node := range nodesStats.Nodes {
+			nodeName := node.Name
+			if nodeName == "" {
+				nodeName = nodeID
+			}
+			labels := []string{cluster, nodeName}
+			ch <- prometheus.MustNewConstMetric(e.nodeHeapUsedPercentDesc, prometheus.GaugeValue, node.JVM.Mem.HeapUsedPercent, labels...)
+			ch <- prometheus.MustNewConstMetric(e.nodeFSAvailableDesc, prometheus.GaugeValue, node.FS.Total.AvailableInBytes, labels...)
+			ch <- prometheus.MustNewConstMetric(e.nodeShardCountDesc, prometheus.GaugeValue, node.Indices.ShardStats.TotalCount, labels...)
+		}
+	}
+
+	// 3) Fetch data streams (to know which backing indices to care about)
 	dataStreamIndexMap, err := e.fetchDataStreamLatestIndices()
 	if err != nil {
 		log.Printf("error fetching data streams: %v", err)
@@ -184,22 +298,51 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	for indexName := range dataStreamIndexMap {
 		backingIndices = append(backingIndices, indexName)
 	}
+	sort.Strings(backingIndices) // stable cache keys and request URLs
 
 	if len(backingIndices) == 0 {
 		// nothing to do
 		return
 	}
 
-	// 3) Fetch stats + cat indices
-	stats, err := e.fetchStatsStoreForIndices(backingIndices)
-	if err != nil {
-		log.Printf("error fetching stats store: %v", err)
+	// 4) Fetch stats + cat indices concurrently; each independently hits the
+	// scrape cache, so on a cache hit this is effectively free.
+	var stats *statsStoreResponse
+	var catIndices []catIndexEntry
+	g, _ := errgroup.WithContext(context.Background())
+	g.Go(func() error {
+		s, err := e.fetchStatsStoreForIndices(backingIndices)
+		if err != nil {
+			return fmt.Errorf("fetching stats store: %w", err)
+		}
+		stats = s
+		return nil
+	})
+	g.Go(func() error {
+		c, err := e.fetchCatIndicesForIndices(backingIndices)
+		if err != nil {
+			return fmt.Errorf("fetching cat indices: %w", err)
+		}
+		catIndices = c
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		log.Printf("error collecting index stats: %v", err)
 		return
 	}
-	catIndices, err := e.fetchCatIndicesForIndices(backingIndices)
+
+	// ISM rollover policies are best-effort: clusters without the ISM plugin,
+	// or without any policy attached to these data streams, simply fall back
+	// to the targetShardSizeBytes heuristic below.
+	ismPolicies, err := e.fetchISMPolicies()
 	if err != nil {
-		log.Printf("error fetching cat indices: %v", err)
-		return
+		log.Printf("ISM policies unavailable, falling back to target shard size heuristic: %v", err)
+		ismPolicies = map[string]ismPolicy{}
+	}
+	ismExplain, err := e.fetchISMExplain(backingIndices)
+	if err != nil {
+		log.Printf("ISM explain unavailable, falling back to target shard size heuristic: %v", err)
+		ismExplain = &ismExplainResponse{Indices: map[string]ismExplainIndex{}}
 	}
 
 	// build: index -> primary shard count (only for backing indices)
@@ -230,8 +373,13 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		avgSize := primaryStoreBytes / float64(primaryCount)
 		recommended := math.Ceil(primaryStoreBytes / e.targetShardSizeBytes)
 
+		proj := e.rolloverProjectionFor(indexName, ismPolicies, ismExplain, primaryStoreBytes, primaryCount)
+		if proj != nil && proj.recommendedPrimaryShards > 0 {
+			recommended = proj.recommendedPrimaryShards
+		}
+
 		shardOK := 1.0
-		if avgSize > e.targetShardSizeBytes {
+		if avgSize > e.targetShardSizeBytes || !clusterCapacityOK {
 			shardOK = 0.0
 		}
 
@@ -267,12 +415,58 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 			shardOK,
 			labels...,
 		)
+
+		if proj != nil {
+			if proj.minPrimaryShardSizeBytes > 0 {
+				ch <- prometheus.MustNewConstMetric(
+					e.rolloverMinPrimaryShardSizeDesc,
+					prometheus.GaugeValue,
+					proj.minPrimaryShardSizeBytes,
+					labels...,
+				)
+			}
+			ch <- prometheus.MustNewConstMetric(
+				e.ismPolicyInfoDesc,
+				prometheus.GaugeValue,
+				1,
+				append(append([]string{}, labels...), proj.policyID)...,
+			)
+			if proj.haveTimeToRollover {
+				ch <- prometheus.MustNewConstMetric(
+					e.timeToRolloverDesc,
+					prometheus.GaugeValue,
+					proj.timeToRolloverSeconds,
+					labels...,
+				)
+			}
+		}
 	}
 
 }
 
 // ------------ HTTP helpers ------------
 
+// getAWSCredentials returns cached AWS credentials, refreshing them only
+// when they're within 5 minutes of expiry. Every scrape calling
+// awsCredsProvider.Retrieve directly would otherwise hit IMDS/STS on each
+// Prometheus scrape.
+func (e *Exporter) getAWSCredentials(ctx context.Context) (aws.Credentials, error) {
+	e.awsCredsMu.Lock()
+	defer e.awsCredsMu.Unlock()
+
+	if e.awsCredsCached && !(e.awsCachedCreds.CanExpire && time.Until(e.awsCachedCreds.Expires) < 5*time.Minute) {
+		return e.awsCachedCreds, nil
+	}
+
+	creds, err := e.awsCredsProvider.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	e.awsCachedCreds = creds
+	e.awsCredsCached = true
+	return creds, nil
+}
+
 func (e *Exporter) doRequest(method, path string) (*http.Response, error) {
 	req, err := http.NewRequest(method, e.baseURL+path, nil)
 	if err != nil {
@@ -285,7 +479,7 @@ func (e *Exporter) doRequest(method, path string) (*http.Response, error) {
 			return nil, fmt.Errorf("IAM auth enabled but signer or credentials provider is not initialized")
 		}
 
-		creds, err := e.awsCredsProvider.Retrieve(context.Background())
+		creds, err := e.getAWSCredentials(context.Background())
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
 		}
@@ -338,7 +532,20 @@ func (e *Exporter) fetchClusterName() error {
 	return nil
 }
 
+// fetchStatsStoreForIndices returns the /_stats/store response for indices,
+// served from the scrape cache (keyed by endpoint + index set) when fresh.
 func (e *Exporter) fetchStatsStoreForIndices(indices []string) (*statsStoreResponse, error) {
+	key := "/_stats/store:" + strings.Join(indices, ",")
+	v, err := e.cache.fetch(key, func() (interface{}, error) {
+		return e.fetchStatsStoreForIndicesUncached(indices)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*statsStoreResponse), nil
+}
+
+func (e *Exporter) fetchStatsStoreForIndicesUncached(indices []string) (*statsStoreResponse, error) {
 	if len(indices) == 0 {
 		return &statsStoreResponse{Indices: map[string]struct {
 			Primaries struct {
@@ -363,7 +570,20 @@ func (e *Exporter) fetchStatsStoreForIndices(indices []string) (*statsStoreRespo
 	return &stats, nil
 }
 
+// fetchCatIndicesForIndices returns the /_cat/indices response for indices,
+// served from the scrape cache (keyed by endpoint + index set) when fresh.
 func (e *Exporter) fetchCatIndicesForIndices(indices []string) ([]catIndexEntry, error) {
+	key := "/_cat/indices:" + strings.Join(indices, ",")
+	v, err := e.cache.fetch(key, func() (interface{}, error) {
+		return e.fetchCatIndicesForIndicesUncached(indices)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]catIndexEntry), nil
+}
+
+func (e *Exporter) fetchCatIndicesForIndicesUncached(indices []string) ([]catIndexEntry, error) {
 	if len(indices) == 0 {
 		return nil, nil
 	}
@@ -383,8 +603,19 @@ func (e *Exporter) fetchCatIndicesForIndices(indices []string) ([]catIndexEntry,
 }
 
 // fetchDataStreamLatestIndices returns a map[backingIndexName]dataStreamName
-// but only for the latest backing index in each data stream.
+// but only for the latest backing index in each data stream. It is served
+// from the scrape cache when fresh.
 func (e *Exporter) fetchDataStreamLatestIndices() (map[string]string, error) {
+	v, err := e.cache.fetch("/_data_stream", func() (interface{}, error) {
+		return e.fetchDataStreamLatestIndicesUncached()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]string), nil
+}
+
+func (e *Exporter) fetchDataStreamLatestIndicesUncached() (map[string]string, error) {
 	resp, err := e.doRequest("GET", "/_data_stream")
 	if err != nil {
 		return nil, err
@@ -398,6 +629,9 @@ func (e *Exporter) fetchDataStreamLatestIndices() (map[string]string, error) {
 
 	result := make(map[string]string)
 	for _, ds := range dsResp.DataStreams {
+		if !e.dataStreamAllowed(ds.Name) {
+			continue
+		}
 		if len(ds.Indices) == 0 {
 			continue
 		}
@@ -410,6 +644,36 @@ func (e *Exporter) fetchDataStreamLatestIndices() (map[string]string, error) {
 
 // ------------ main ------------
 
+// parseOptionalFloatEnv parses a float env var, returning 0 (meaning
+// "threshold disabled") if it's unset or invalid.
+func parseOptionalFloatEnv(name string) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0
+	}
+	val, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("invalid %s=%q, ignoring: %v", name, raw, err)
+		return 0
+	}
+	return val
+}
+
+// scrapeCacheTTLFromEnv parses SCRAPE_CACHE_TTL (a Go duration string, e.g.
+// "30s"), defaulting to 30 seconds.
+func scrapeCacheTTLFromEnv() time.Duration {
+	raw := os.Getenv("SCRAPE_CACHE_TTL")
+	if raw == "" {
+		return 30 * time.Second
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("invalid SCRAPE_CACHE_TTL=%q, using default of 30s: %v", raw, err)
+		return 30 * time.Second
+	}
+	return ttl
+}
+
 func main() {
 	baseURL := os.Getenv("OPENSEARCH_URL")
 	if baseURL == "" {
@@ -440,12 +704,45 @@ func main() {
 	}
 	awsRegion := os.Getenv("OPENSEARCH_AWS_REGION")
 	awsService := os.Getenv("OPENSEARCH_AWS_SERVICE")
+	awsRoleARN := os.Getenv("OPENSEARCH_AWS_ROLE_ARN")
+	awsRoleSessionName := os.Getenv("OPENSEARCH_AWS_ROLE_SESSION_NAME")
+	awsExternalID := os.Getenv("OPENSEARCH_AWS_EXTERNAL_ID")
 
-	exporter := NewExporter(baseURL, username, password, targetBytes, useIAM, awsRegion, awsService)
+	exporter, err := NewExporterWithAssumeRole(baseURL, username, password, targetBytes, useIAM, awsRegion, awsService, awsRoleARN, awsRoleSessionName, awsExternalID)
+	if err != nil {
+		log.Fatalf("failed to create exporter: %v", err)
+	}
+	exporter.dataStreamInclude = parseGlobList(os.Getenv("DATASTREAM_INCLUDE"))
+	exporter.dataStreamExclude = parseGlobList(os.Getenv("DATASTREAM_EXCLUDE"))
+	exporter.cache.ttl = scrapeCacheTTLFromEnv()
+	exporter.nodeHeapMaxPercent = parseOptionalFloatEnv("NODE_HEAP_MAX_PERCENT")
+	exporter.nodeDiskMinFreeBytes = parseOptionalFloatEnv("NODE_DISK_MIN_FREE_GB") * 1024 * 1024 * 1024
 	prometheus.MustRegister(exporter)
 
 	http.Handle("/metrics", promhttp.Handler())
 
+	// Multi-target scraping: /probe?target=<url> builds or reuses an Exporter
+	// for the requested cluster, keyed by target URL. A YAML config file
+	// (OPENSEARCH_PROBE_CONFIG) can pre-declare targets with their own
+	// credentials, auth mode, IAM region/service, and shard size so
+	// Prometheus can relabel __param_target across a fleet of managed
+	// OpenSearch domains.
+	probeConfig := &Config{}
+	if probeConfigPath := os.Getenv("OPENSEARCH_PROBE_CONFIG"); probeConfigPath != "" {
+		loaded, err := loadConfig(probeConfigPath)
+		if err != nil {
+			log.Fatalf("failed to load probe config: %v", err)
+		}
+		probeConfig = loaded
+	}
+	// Targets not declared in probeConfig are only ever probed with auth=none:
+	// an undeclared target has no allowlisted credentials or IAM region/service,
+	// so honoring a caller-supplied auth=iam there would let anyone who can
+	// reach /probe sign requests to an arbitrary URL with the exporter's own
+	// AWS identity. Set this to probe ad-hoc targets anyway (still auth=none).
+	allowUndeclaredTargets := os.Getenv("OPENSEARCH_PROBE_ALLOW_UNDECLARED_TARGETS") == "true"
+	http.Handle("/probe", newProbeHandler(probeConfig, targetBytes, allowUndeclaredTargets))
+
 	authMode := "no-auth"
 	if useIAM {
 		authMode = "iam"
@@ -454,8 +751,8 @@ func main() {
 	}
 
 	log.Printf(
-		"Starting OpenSearch data stream shard exporter on %s, target shard %.1f GB, auth=%s",
-		listenAddr, targetGB, authMode,
+		"Starting OpenSearch data stream shard exporter on %s, target shard %.1f GB, auth=%s, probe targets=%d",
+		listenAddr, targetGB, authMode, len(probeConfig.Targets),
 	)
 	if err := http.ListenAndServe(listenAddr, nil); err != nil {
 		log.Fatalf("error starting HTTP server: %v", err)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// /_nodes/stats/jvm,fs,indices
+type nodesStatsResponse struct {
+	Nodes map[string]nodeStats `json:"nodes"`
+}
+
+type nodeStats struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	JVM   struct {
+		Mem struct {
+			HeapUsedPercent float64 `json:"heap_used_percent"`
+		} `json:"mem"`
+	} `json:"jvm"`
+	FS struct {
+		Total struct {
+			AvailableInBytes float64 `json:"available_in_bytes"`
+		} `json:"total"`
+	} `json:"fs"`
+	Indices struct {
+		ShardStats struct {
+			TotalCount float64 `json:"total_count"`
+		} `json:"shard_stats"`
+	} `json:"indices"`
+}
+
+func (n nodeStats) isDataNode() bool {
+	for _, role := range n.Roles {
+		if role == "data" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchNodesStats fetches per-node JVM, filesystem, and shard-count stats.
+func (e *Exporter) fetchNodesStats() (*nodesStatsResponse, error) {
+	resp, err := e.doRequest("GET", "/_nodes/stats/jvm,fs,indices")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed nodesStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// clusterCapacityOK reports whether every data node in stats is within the
+// configured heap and disk thresholds. It returns true (healthy) when no
+// thresholds are configured.
+func (e *Exporter) clusterCapacityOK(stats *nodesStatsResponse) bool {
+	for _, node := range stats.Nodes {
+		if !node.isDataNode() {
+			continue
+		}
+		if e.nodeHeapMaxPercent > 0 && node.JVM.Mem.HeapUsedPercent > e.nodeHeapMaxPercent {
+			return false
+		}
+		if e.nodeDiskMinFreeBytes > 0 && node.FS.Total.AvailableInBytes < e.nodeDiskMinFreeBytes {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler serves per-target metrics at /probe?target=<url>, following
+// the Prometheus multi-target exporter pattern (see the blackbox_exporter and
+// sql_exporter /probe conventions). Unlike /metrics, which exposes the
+// exporter's own process metrics, each /probe request builds (or reuses) an
+// Exporter scoped to the requested target and returns only that cluster's
+// data stream metrics. Auth mode is never taken from the request: it comes
+// from the target's entry in the YAML allowlist, or is "none" for targets
+// not declared there.
+type probeHandler struct {
+	config                      *Config
+	defaultTargetShardSizeBytes float64
+	allowUndeclaredTargets      bool
+
+	mu   sync.Mutex
+	pool map[string]*Exporter
+}
+
+func newProbeHandler(config *Config, defaultTargetShardSizeBytes float64, allowUndeclaredTargets bool) *probeHandler {
+	return &probeHandler{
+		config:                      config,
+		defaultTargetShardSizeBytes: defaultTargetShardSizeBytes,
+		allowUndeclaredTargets:      allowUndeclaredTargets,
+		pool:                        make(map[string]*Exporter),
+	}
+}
+
+// buildExporter constructs an Exporter for target, taking its auth mode and
+// credentials solely from the YAML config (matched by URL). A target not
+// declared in the YAML config is only honored as a plain, unauthenticated
+// probe: without an explicit entry in the allowlist, an attacker reaching
+// /probe could otherwise force the exporter to sign requests with its
+// ambient AWS identity against an arbitrary URL. Set
+// OPENSEARCH_PROBE_ALLOW_UNDECLARED_TARGETS=true to opt back into ad-hoc
+// (always auth=none) targets.
+func (h *probeHandler) buildExporter(target string) (*Exporter, error) {
+	tc, declared := h.config.findTarget(target)
+	if !declared && !h.allowUndeclaredTargets {
+		return nil, fmt.Errorf("target %q is not declared in the probe config", target)
+	}
+
+	targetShardSizeBytes := h.defaultTargetShardSizeBytes
+	username, password := "", ""
+	useIAM := false
+	awsRegion, awsService := "", ""
+	awsRoleARN, awsRoleSessionName, awsExternalID := "", "", ""
+	timeout := 10 * time.Second
+
+	if declared {
+		if tc.TargetShardSizeGB > 0 {
+			targetShardSizeBytes = tc.TargetShardSizeGB * 1024 * 1024 * 1024
+		}
+		if tc.TimeoutSeconds > 0 {
+			timeout = time.Duration(tc.TimeoutSeconds) * time.Second
+		}
+		username = tc.Username
+		password = tc.Password
+		awsRegion = tc.AWSRegion
+		awsService = tc.AWSService
+		awsRoleARN = tc.AWSRoleARN
+		awsRoleSessionName = tc.AWSRoleSessionName
+		awsExternalID = tc.AWSExternalID
+		useIAM = tc.Auth == "iam"
+	} else {
+		// Undeclared targets are explicitly opted into via
+		// OPENSEARCH_PROBE_ALLOW_UNDECLARED_TARGETS and never get IAM: they
+		// have no allowlisted entry to carry credentials or a region/service,
+		// so honoring auth=iam here would sign requests to an arbitrary
+		// caller-supplied URL with the exporter's own AWS identity.
+		useIAM = false
+	}
+
+	exp, err := NewExporterWithAssumeRole(target, username, password, targetShardSizeBytes, useIAM, awsRegion, awsService, awsRoleARN, awsRoleSessionName, awsExternalID)
+	if err != nil {
+		return nil, err
+	}
+	exp.client.Timeout = timeout
+	// Data-stream filters and node capacity thresholds are process-wide env
+	// config, so they apply the same way to every probed target.
+	exp.dataStreamInclude = parseGlobList(os.Getenv("DATASTREAM_INCLUDE"))
+	exp.dataStreamExclude = parseGlobList(os.Getenv("DATASTREAM_EXCLUDE"))
+	exp.nodeHeapMaxPercent = parseOptionalFloatEnv("NODE_HEAP_MAX_PERCENT")
+	exp.nodeDiskMinFreeBytes = parseOptionalFloatEnv("NODE_DISK_MIN_FREE_GB") * 1024 * 1024 * 1024
+	exp.cache.ttl = scrapeCacheTTLFromEnv()
+	return exp, nil
+}
+
+// exporterFor returns the pooled Exporter for target, creating it on first use.
+func (h *probeHandler) exporterFor(target string) (*Exporter, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if exp, ok := h.pool[target]; ok {
+		return exp, nil
+	}
+
+	exp, err := h.buildExporter(target)
+	if err != nil {
+		return nil, err
+	}
+	h.pool[target] = exp
+	return exp, nil
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	exp, err := h.exporterFor(target)
+	if err != nil {
+		log.Printf("probe: failed to build exporter for target %s: %v", target, err)
+		http.Error(w, "failed to initialize exporter for target", http.StatusBadRequest)
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exp)
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	log.Printf("probe: target=%s duration=%s", target, time.Since(start))
+}